@@ -6,7 +6,6 @@ import (
 	"go/token"
 	"go/types"
 	"io"
-	"log"
 	"strconv"
 
 	ctypes "github.com/goplus/c2go/clang/types"
@@ -18,17 +17,98 @@ var (
 	ErrInvalidType = errors.New("invalid type")
 )
 
+// errEllipsis is a sentinel returned by parseExpr when it encounters a bare
+// "..." where a parameter type was expected; parseParamsExpr uses it to mark
+// the enclosing signature as variadic.
+var errEllipsis = errors.New("ellipsis")
+
+// Qualifiers holds C type metadata that ParseType extracts but that has no
+// direct representation in go/types: _Atomic, _Alignas and the
+// __attribute__((vector_size(n))) / _Nullable / _Nonnull annotations Clang
+// pretty-prints into a qualType.
+type Qualifiers struct {
+	IsAtomic   bool
+	Alignment  int // from _Alignas or __attribute__((aligned(n))), 0 if none
+	Nullable   bool
+	Nonnull    bool
+	VectorSize int // from __attribute__((vector_size(n))), 0 if none
+
+	// ArrayMinLen only ever holds the last `static` array bound seen, and
+	// ArrayConst is set if any array dimension's qualifier list included
+	// const, so a qualType with more than one array dimension - or more
+	// than one array-typed parameter - loses which dimension each came
+	// from; walk the ArrayType nodes from ParseTypeExpr directly if every
+	// dimension's metadata is needed.
+	ArrayMinLen int64        // from `static` inside [...], e.g. char[static 16]; 0 if not specified
+	ArrayConst  bool         // whether a type-qualifier-list inside [...] included const
+	Storage     StorageClass // register/auto/static seen on the declaration itself
+}
+
+// StorageClass records which C storage-class specifier, if any, qualified a
+// declaration; ParseType has no go/types equivalent to put this on, so it
+// rides along on Qualifiers instead.
+type StorageClass int
+
+const (
+	StorageAuto StorageClass = 1 << iota
+	StorageRegister
+	StorageStatic
+)
+
 // -----------------------------------------------------------------------------
 
 const (
 	FlagIsParam = 1 << iota
 	FlagGetRetType
+	// FlagRecover makes ParseType/ParseTypeQual/ParseTypeExpr recover from a
+	// malformed parameter instead of aborting the whole qualType: the bad
+	// parameter is replaced with a BadType node, the error is recorded
+	// rather than returned, and parsing resumes at the next parameter. If
+	// the qualType is malformed outside of any parameter list, the whole
+	// type is replaced with a single BadType instead.
+	FlagRecover
 )
 
 func isParam(flags int) bool {
 	return (flags & FlagIsParam) != 0
 }
 
+// typeKeywords are the identifiers the "ident" case in parseExpr recognizes
+// as part of a type rather than as the name that follows one. isTypeKeyword
+// is used to decide, after accumulating unsigned/short/long/signed/_Complex
+// flags, whether the next identifier continues the base type - either
+// another modifier (the "long" in "unsigned long") or the base-type name
+// those modifiers combine with (the "char" in "unsigned char") - or is
+// actually a trailing parameter name (the "x" in "unsigned long x").
+var typeKeywords = map[string]bool{
+	"unsigned":      true,
+	"short":         true,
+	"long":          true,
+	"signed":        true,
+	"const":         true,
+	"_Complex":      true,
+	"volatile":      true,
+	"restrict":      true,
+	"_Nullable":     true,
+	"_Nonnull":      true,
+	"register":      true,
+	"auto":          true,
+	"static":        true,
+	"__attribute__": true,
+	"_Alignas":      true,
+	"_Atomic":       true,
+	// base-type names lookupType combines with the flags above
+	"char":     true,
+	"int":      true,
+	"float":    true,
+	"double":   true,
+	"__int128": true,
+}
+
+func isTypeKeyword(lit string) bool {
+	return typeKeywords[lit]
+}
+
 func getRetType(flags int) bool {
 	return (flags & FlagGetRetType) != 0
 }
@@ -47,16 +127,81 @@ func getRetType(flags int) bool {
 //   void
 //   ...
 func ParseType(fset *token.FileSet, pkg *types.Package, scope *types.Scope, qualType string, flags int) (t types.Type, isConst bool, err error) {
-	p := &parser{pkg: pkg, scope: scope}
+	t, isConst, _, err = ParseTypeQual(fset, pkg, scope, qualType, flags)
+	return
+}
+
+// ParseTypeQual is like ParseType but also returns the Qualifiers that
+// qualType carries and that don't fit into go/types.Type, such as
+// _Atomic, _Alignas or __attribute__((vector_size(n))). With FlagRecover
+// set, each malformed parameter (or, outside any parameter list, the whole
+// malformed qualType) lowers to unsafe.Pointer instead of returning err;
+// use ParseTypeExpr to also recover the individual errors that were
+// swallowed along the way.
+func ParseTypeQual(fset *token.FileSet, pkg *types.Package, scope *types.Scope, qualType string, flags int) (t types.Type, isConst bool, quals Qualifiers, err error) {
+	p := &parser{pkg: pkg, scope: scope, recover: flags&FlagRecover != 0}
 	file := fset.AddFile("", fset.Base(), len(qualType))
 	p.s.Init(file, qualType, nil)
 
-	if t, isConst, err = p.parse(flags); err != nil {
+	x, e := p.parseExpr(flags)
+	if e != nil {
+		if x, e = p.recoverBad(e); e != nil {
+			err = e
+			return
+		}
+	} else if p.tok != token.EOF {
+		if x, e = p.recoverBad(p.newError("unexpect token " + p.tok.String())); e != nil {
+			err = e
+			return
+		}
+	}
+	if t, err = p.lower(x, flags); err != nil {
 		return
 	}
-	if p.tok != token.EOF {
-		err = p.newError("unexpect token " + p.tok.String())
+	isConst = isConstExpr(x)
+	quals = p.quals
+	return
+}
+
+// ParseTypeExpr parses qualType into its TypeExpr parse tree without
+// resolving any of the identifiers it contains against a scope. Callers
+// that need to inspect qualifiers, parameter names, or re-render the C type
+// for diagnostics can walk the returned tree; use Lower to resolve it into a
+// go/types.Type the way ParseType would.
+//
+// With FlagRecover set, a malformed parameter or qualType is replaced with a
+// BadType node rather than aborting the parse, and the error it would have
+// returned is appended to errs instead; errs has at most one entry outside
+// FlagRecover mode.
+func ParseTypeExpr(fset *token.FileSet, qualType string, flags int) (x TypeExpr, errs []error) {
+	p := &parser{recover: flags&FlagRecover != 0}
+	file := fset.AddFile("", fset.Base(), len(qualType))
+	p.s.Init(file, qualType, nil)
+
+	var err error
+	if x, err = p.parseExpr(flags); err != nil {
+		if x, err = p.recoverBad(err); err != nil {
+			return nil, []error{err}
+		}
+	} else if p.tok != token.EOF {
+		e := p.newError("unexpect token " + p.tok.String())
+		if x, err = p.recoverBad(e); err != nil {
+			return nil, []error{err}
+		}
 	}
+	errs = p.errs
+	return
+}
+
+// Lower resolves a TypeExpr tree produced by ParseTypeExpr against pkg and
+// scope, returning the same (types.Type, isConst) pair ParseType would have
+// produced directly from the same qualType.
+func Lower(pkg *types.Package, scope *types.Scope, x TypeExpr, flags int) (t types.Type, isConst bool, err error) {
+	p := &parser{pkg: pkg, scope: scope}
+	if t, err = p.lower(x, flags); err != nil {
+		return
+	}
+	isConst = isConstExpr(x)
 	return
 }
 
@@ -70,6 +215,33 @@ type parser struct {
 	pos token.Pos
 	tok token.Token
 	lit string
+
+	quals     Qualifiers
+	recover   bool
+	errs      []error
+	paramName string // identifier following the current parameter's type, set by parseExpr
+}
+
+// skipToSync advances the scanner to the next synchronizing token (",",
+// ")", "]" or EOF) so FlagRecover can resume parsing after a bad subtree.
+func (p *parser) skipToSync() {
+	for p.tok != token.COMMA && p.tok != token.RPAREN && p.tok != token.RBRACK && p.tok != token.EOF {
+		p.next()
+	}
+}
+
+// recoverBad, in FlagRecover mode, records err and returns a BadType
+// placeholder spanning the source skipped to reach the next synchronizing
+// token; outside FlagRecover mode it records nothing and returns err
+// unchanged.
+func (p *parser) recoverBad(err error) (TypeExpr, error) {
+	if !p.recover {
+		return nil, err
+	}
+	from, lit := p.pos, p.lit
+	p.errs = append(p.errs, err)
+	p.skipToSync()
+	return &BadType{Literal: lit, From: from, To: p.pos}, nil
 }
 
 func (p *parser) next() {
@@ -145,8 +317,7 @@ func (p *parser) lookupType(lit string, flags int) (t types.Type, err error) {
 				}
 			}
 		}
-		log.Fatalln("lookupType: TODO - invalid type")
-		return nil, ErrInvalidType
+		return nil, p.newErrorf("lookupType: invalid type - %v (flags=%x)", lit, flags)
 	}
 	if lit == "int" {
 		return types.Typ[types.Int32], nil
@@ -170,65 +341,19 @@ var intTypes = [...]types.Type{
 	flagShort | flagLong | flagLongLong | flagUnsigned: nil,
 }
 
-func (p *parser) parseArray(t types.Type, inFlags int) (types.Type, error) {
-	if t == nil {
-		return nil, p.newError("array to nil")
-	}
-	var n int64
-	var err error
-	p.next()
-	switch p.tok {
-	case token.RBRACK: // ]
-		n = -1
-	case token.INT:
-		if n, err = strconv.ParseInt(p.lit, 10, 64); err != nil {
-			return nil, p.newError(err.Error())
-		}
-		if err = p.expect(token.RBRACK); err != nil { // ]
-			return nil, err
-		}
-	default:
-		return nil, p.newError("array length not an integer")
-	}
-	if isParam(inFlags) {
-		t = p.newPointer(t)
-	} else {
-		t = types.NewArray(t, n)
-	}
-	return t, nil
-}
-
-func (p *parser) parseArrays(t types.Type, inFlags int) (ret types.Type, err error) {
-	for {
-		if ret, err = p.parseArray(t, inFlags); err != nil {
-			return
-		}
-		p.next()
-		if p.tok == token.EOF {
-			return
-		}
-		t = ret
-	}
-}
-
-func (p *parser) parseFunc(pkg *types.Package, t types.Type, inFlags int) (ret types.Type, err error) {
-	var results *types.Tuple
-	if ctypes.NotVoid(t) {
-		results = types.NewTuple(types.NewParam(token.NoPos, pkg, "", t))
-	}
-	args, err := p.parseArgs(pkg)
-	if err != nil {
-		return
-	}
-	return types.NewSignature(nil, types.NewTuple(args...), results, false), nil
-}
+// -----------------------------------------------------------------------------
+// Pass 1: parseExpr builds a TypeExpr tree from the qualType token stream.
+// It never touches p.pkg/p.scope - identifier resolution happens in lower.
 
-func (p *parser) parse(inFlags int) (t types.Type, isConst bool, err error) {
+func (p *parser) parseExpr(inFlags int) (x TypeExpr, err error) {
 	flags := 0
+	var pendingConst, pendingVolatile, pendingRestrict bool
 	for {
 		p.next()
 	retry:
 		switch p.tok {
+		case token.ELLIPSIS: // ...
+			return nil, errEllipsis
 		case token.IDENT:
 		ident:
 			switch p.lit {
@@ -245,51 +370,123 @@ func (p *parser) parse(inFlags int) (t types.Type, isConst bool, err error) {
 			case "signed":
 				flags |= flagSigned
 			case "const":
-				isConst = true
+				if x != nil {
+					x = wrapQual(x, true, false, false)
+				} else {
+					pendingConst = true
+				}
 			case "_Complex":
 				flags |= flagComplex
-			case "volatile", "restrict", "_Nullable", "_Nonnull":
+			case "volatile":
+				if x != nil {
+					x = wrapQual(x, false, true, false)
+				} else {
+					pendingVolatile = true
+				}
+			case "restrict":
+				if x != nil {
+					x = wrapQual(x, false, false, true)
+				} else {
+					pendingRestrict = true
+				}
+			case "_Nullable":
+				p.quals.Nullable = true
+			case "_Nonnull":
+				p.quals.Nonnull = true
+			case "register":
+				p.quals.Storage |= StorageRegister
+			case "auto":
+				p.quals.Storage |= StorageAuto
+			case "static":
+				p.quals.Storage |= StorageStatic
+			case "__attribute__":
+				if err = p.parseAttribute(); err != nil {
+					return nil, err
+				}
+			case "_Alignas":
+				if err = p.parseAlignas(); err != nil {
+					return nil, err
+				}
+			case "_Atomic":
+				p.quals.IsAtomic = true
+				var inner TypeExpr
+				if inner, err = p.parseAtomicExpr(); err != nil {
+					return nil, err
+				}
+				if inner != nil {
+					if x != nil {
+						return nil, p.newError("illegal syntax: multiple types?")
+					}
+					x = inner
+					if pendingConst || pendingVolatile || pendingRestrict {
+						x = wrapQual(x, pendingConst, pendingVolatile, pendingRestrict)
+						pendingConst, pendingVolatile, pendingRestrict = false, false, false
+					}
+					p.next()
+				}
+				goto retry
 			case "struct", "union":
 				p.next()
 				if p.tok != token.IDENT {
-					log.Fatalln("c.types.ParseType: struct/union - TODO:", p.lit, "@", p.pos)
+					return nil, p.newErrorf("c.types.ParseType: struct/union - unexpected %v @ %v", p.lit, p.pos)
 				}
 				fallthrough
 			default:
-				if t != nil {
-					return nil, false, p.newError("illegal syntax: multiple types?")
+				if x != nil {
+					if isParam(inFlags) && p.paramName == "" {
+						p.paramName = p.lit
+						p.next()
+						goto retry
+					}
+					return nil, p.newError("illegal syntax: multiple types?")
 				}
-				if t, err = p.lookupType(p.lit, flags); err != nil {
-					return
+				x = &IdentType{Name: p.lit, Flags: flags, NamePos: p.pos}
+				if pendingConst || pendingVolatile || pendingRestrict {
+					x = wrapQual(x, pendingConst, pendingVolatile, pendingRestrict)
+					pendingConst, pendingVolatile, pendingRestrict = false, false, false
 				}
 				flags = 0
 			}
 			if flags != 0 {
 				p.next()
-				if p.tok == token.IDENT {
+				if p.tok == token.IDENT && isTypeKeyword(p.lit) {
 					goto ident
 				}
-				if t != nil {
-					return nil, false, p.newError("illegal syntax: multiple types?")
+				if x != nil {
+					return nil, p.newError("illegal syntax: multiple types?")
 				}
-				if t, err = p.lookupType("int", flags); err != nil {
-					return
+				x = &IdentType{Name: "int", Flags: flags, NamePos: p.pos}
+				if pendingConst || pendingVolatile || pendingRestrict {
+					x = wrapQual(x, pendingConst, pendingVolatile, pendingRestrict)
+					pendingConst, pendingVolatile, pendingRestrict = false, false, false
 				}
 				flags = 0
+				if p.tok == token.IDENT {
+					// Not a recognized base-type keyword: it's the identifier
+					// following this implicit-int type, e.g. the "x" in
+					// "unsigned long x". Let the ident case below decide
+					// whether that's a parameter name or an error.
+					goto ident
+				}
 				goto retry
 			}
 		case token.MUL: // *
-			if t == nil {
-				return nil, false, p.newError("pointer to nil")
+			if x == nil {
+				return nil, p.newError("pointer to nil")
 			}
-			t = p.newPointer(t)
+			x = &PointerType{Elem: x, Star: p.pos}
 		case token.LBRACK: // [
-			if t, err = p.parseArrays(t, inFlags); err != nil {
-				return
+			// parseArraysExpr already peeked past the last "]" to decide
+			// whether another dimension follows, so p.tok is already the
+			// token after the array - retry on it instead of letting the
+			// loop advance past it with another p.next().
+			if x, err = p.parseArraysExpr(x); err != nil {
+				return nil, err
 			}
+			goto retry
 		case token.LPAREN: // (
-			if t == nil {
-				return nil, false, p.newError("no function return type")
+			if x == nil {
+				return nil, p.newError("no function return type")
 			}
 			if err = p.expect2(token.MUL, token.XOR); err != nil { // * or ^
 				if getRetType(inFlags) {
@@ -298,21 +495,22 @@ func (p *parser) parse(inFlags int) (t types.Type, isConst bool, err error) {
 				}
 				return
 			}
-			var pkg, isRetFn = p.pkg, false
-			var args []*types.Var
+			isRetFn := false
+			var params []*ParamExpr
+			var variadic, peeked bool
 		nextTok:
 			p.next()
 			switch p.tok {
 			case token.RPAREN: // )
 			case token.LPAREN: // (
 				if !isRetFn {
-					if args, err = p.parseArgs(pkg); err != nil {
-						return
+					if params, variadic, err = p.parseParamsExpr(); err != nil {
+						return nil, err
 					}
 					isRetFn = true
 					goto nextTok
 				}
-				return nil, false, p.newError("expect )")
+				return nil, p.newError("expect )")
 			case token.IDENT:
 				switch p.lit {
 				case "_Nullable", "_Nonnull":
@@ -320,64 +518,307 @@ func (p *parser) parse(inFlags int) (t types.Type, isConst bool, err error) {
 				}
 				fallthrough
 			default:
-				return nil, false, p.newError("expect )")
+				return nil, p.newError("expect )")
 			}
 			p.next()
 			switch p.tok {
 			case token.LPAREN: // (
-				if t, err = p.parseFunc(pkg, t, inFlags); err != nil {
-					return
+				fnParams, fnVariadic, e := p.parseParamsExpr()
+				if e != nil {
+					return nil, e
 				}
+				x = &FuncType{Params: fnParams, Result: x, Variadic: fnVariadic, Rparen: p.pos}
 			case token.LBRACK: // [
-				if t, err = p.parseArrays(t, 0); err != nil {
-					return
+				if x, err = p.parseArraysExpr(x); err != nil {
+					return nil, err
 				}
+				peeked = true
 			default:
-				return nil, false, p.newError("unexpected " + p.tok.String())
+				return nil, p.newError("unexpected " + p.tok.String())
 			}
 			if isRetFn {
 				if getRetType(inFlags) {
 					p.tok = token.EOF
 					return
 				}
-				results := types.NewTuple(types.NewParam(token.NoPos, pkg, "", t))
-				t = types.NewSignature(nil, types.NewTuple(args...), results, false)
-			} else if _, ok := t.(*types.Signature); !ok {
-				t = types.NewPointer(t)
+				x = &FuncType{Params: params, Result: x, Variadic: variadic, Rparen: p.pos}
+			} else if _, ok := x.(*FuncType); !ok {
+				x = &PointerType{Elem: x, Star: p.pos}
+			}
+			if peeked {
+				// p.tok is already the token after the array's last "]".
+				goto retry
 			}
 		case token.RPAREN:
-			if t == nil {
-				t = ctypes.Void
+			if x == nil {
+				x = &IdentType{Name: "void", NamePos: p.pos}
 			}
 			return
 		case token.COMMA, token.EOF:
-			if t == nil {
+			if x == nil {
 				err = io.ErrUnexpectedEOF
 			}
 			return
 		default:
-			log.Fatalln("c.types.ParseType: unknown -", p.tok, p.lit)
+			return nil, p.newErrorf("c.types.ParseType: unknown - %v %v", p.tok, p.lit)
 		}
 	}
 }
 
-func (p *parser) parseArgs(pkg *types.Package) ([]*types.Var, error) {
-	var args []*types.Var
+// parseArrayExpr parses a single `[n]` or `[]` following elem, consuming up
+// to and including the closing "]". The opening "[" is assumed to already
+// be the current token.
+func (p *parser) parseArrayExpr(elem TypeExpr) (*ArrayType, error) {
+	if elem == nil {
+		return nil, p.newError("array to nil")
+	}
+	var n int64
+	var err error
+	isStatic := false
+	p.next()
+qual:
+	if p.tok == token.IDENT {
+		switch p.lit {
+		case "static":
+			isStatic = true
+			p.next()
+			goto qual
+		case "const":
+			p.quals.ArrayConst = true
+			p.next()
+			goto qual
+		case "volatile", "restrict":
+			p.next()
+			goto qual
+		}
+	}
+	switch p.tok {
+	case token.RBRACK: // ]
+		n = -1
+	case token.INT:
+		if n, err = strconv.ParseInt(p.lit, 10, 64); err != nil {
+			return nil, p.newError(err.Error())
+		}
+		if err = p.expect(token.RBRACK); err != nil { // ]
+			return nil, err
+		}
+	default:
+		return nil, p.newError("array length not an integer")
+	}
+	if isStatic {
+		p.quals.ArrayMinLen = n
+	}
+	return &ArrayType{Elem: elem, Len: n, Rbrack: p.pos}, nil
+}
+
+// parseArraysExpr parses one or more consecutive `[n]` suffixes, e.g. the
+// "[3][4]" in "int [3][4]". It stops as soon as the token following a "]"
+// isn't another "[", leaving that token current for the caller - which
+// matters inside a parameter list, where a "]" is routinely followed by a
+// "," or ")" rather than EOF.
+func (p *parser) parseArraysExpr(elem TypeExpr) (x TypeExpr, err error) {
 	for {
-		arg, _, e := p.parse(FlagIsParam)
-		if e != nil {
-			return nil, e
+		var arr *ArrayType
+		if arr, err = p.parseArrayExpr(elem); err != nil {
+			return
 		}
-		if ctypes.NotVoid(arg) {
-			args = append(args, types.NewParam(token.NoPos, pkg, "", arg))
+		x = arr
+		p.next()
+		if p.tok != token.LBRACK {
+			return
 		}
+		elem = arr
+	}
+}
+
+// parseParamsExpr parses a comma-separated parameter list up to (but not
+// including) the closing ")". A trailing "..." marks the list as variadic;
+// it must be the last parameter and carries no type of its own. A lone
+// "void" parameter is kept in the tree as-is; it is elided at lowering time,
+// once it's known to resolve to ctypes.Void.
+func (p *parser) parseParamsExpr() (params []*ParamExpr, variadic bool, err error) {
+	// Keep a nested list's names from leaking into the enclosing parameter.
+	outerName := p.paramName
+	defer func() { p.paramName = outerName }()
+	for {
+		p.paramName = ""
+		x, e := p.parseExpr(FlagIsParam)
+		if e == errEllipsis {
+			variadic = true
+			p.next()
+			break
+		}
+		if e != nil {
+			if x, e = p.recoverBad(e); e != nil {
+				return nil, false, e
+			}
+		}
+		params = append(params, &ParamExpr{Name: p.paramName, Type: x})
 		if p.tok != token.COMMA {
 			break
 		}
 	}
 	if p.tok != token.RPAREN { // )
+		return nil, false, p.newError("expect )")
+	}
+	return params, variadic, nil
+}
+
+// parseAttribute consumes a GCC/Clang __attribute__((...)) token stream,
+// recording the argument of a vector_size(n) or aligned(n) attribute if
+// present. Everything else inside the parens is skipped.
+func (p *parser) parseAttribute() error {
+	if err := p.expect(token.LPAREN); err != nil { // (
+		return err
+	}
+	if err := p.expect(token.LPAREN); err != nil { // (
+		return err
+	}
+	depth := 2
+	lastIdent := ""
+	for depth > 0 {
+		p.next()
+		switch p.tok {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			depth--
+		case token.IDENT:
+			lastIdent = p.lit
+		case token.INT:
+			if n, err := strconv.ParseInt(p.lit, 10, 64); err == nil {
+				switch lastIdent {
+				case "vector_size":
+					p.quals.VectorSize = int(n)
+				case "aligned":
+					p.quals.Alignment = int(n)
+				}
+			}
+		case token.EOF:
+			return p.newError("unterminated __attribute__")
+		}
+	}
+	return nil
+}
+
+// parseAlignas consumes a parenthesized _Alignas argument, which is either
+// an integer constant or a type-name. Only the integer form yields an
+// alignment hint; the type form is skipped since it doesn't change the
+// identity of the surrounding type.
+func (p *parser) parseAlignas() error {
+	if err := p.expect(token.LPAREN); err != nil { // (
+		return err
+	}
+	p.next()
+	if p.tok == token.INT {
+		n, err := strconv.ParseInt(p.lit, 10, 64)
+		if err != nil {
+			return p.newError(err.Error())
+		}
+		p.quals.Alignment = int(n)
+		return p.expect(token.RPAREN) // )
+	}
+	for depth := 1; depth > 0; {
+		switch p.tok {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			depth--
+		case token.EOF:
+			return p.newError("unterminated _Alignas")
+		}
+		if depth > 0 {
+			p.next()
+		}
+	}
+	return nil
+}
+
+// parseAtomicExpr parses the optional parenthesized operand of
+// _Atomic(type). It returns a nil expr and no error for the bare qualifier
+// form (`_Atomic int`), leaving p.tok on the next unconsumed token.
+func (p *parser) parseAtomicExpr() (TypeExpr, error) {
+	p.next()
+	if p.tok != token.LPAREN {
+		return nil, nil
+	}
+	inner, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != token.RPAREN {
 		return nil, p.newError("expect )")
 	}
+	return inner, nil
+}
+
+// -----------------------------------------------------------------------------
+// Pass 2: lower resolves a TypeExpr tree against p.pkg/p.scope into a
+// go/types.Type, the way the original single-pass parser used to.
+
+func (p *parser) lower(x TypeExpr, inFlags int) (types.Type, error) {
+	switch n := x.(type) {
+	case nil:
+		return nil, nil
+	case *IdentType:
+		return p.lookupType(n.Name, n.Flags)
+	case *QualType:
+		return p.lower(n.Inner, inFlags)
+	case *PointerType:
+		elem, err := p.lower(n.Elem, inFlags)
+		if err != nil {
+			return nil, err
+		}
+		return p.newPointer(elem), nil
+	case *ArrayType:
+		elem, err := p.lower(n.Elem, inFlags)
+		if err != nil {
+			return nil, err
+		}
+		if isParam(inFlags) {
+			return p.newPointer(elem), nil
+		}
+		return types.NewArray(elem, n.Len), nil
+	case *FuncType:
+		var results *types.Tuple
+		if n.Result != nil {
+			rt, err := p.lower(n.Result, inFlags)
+			if err != nil {
+				return nil, err
+			}
+			if ctypes.NotVoid(rt) {
+				results = types.NewTuple(types.NewParam(token.NoPos, p.pkg, "", rt))
+			}
+		}
+		args, err := p.lowerParams(n.Params, n.Variadic)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewSignature(nil, types.NewTuple(args...), results, n.Variadic), nil
+	case *BadType:
+		return types.Typ[types.UnsafePointer], nil
+	}
+	return nil, p.newErrorf("lower: unexpected TypeExpr %T", x)
+}
+
+// lowerParams lowers params into *types.Var arguments, eliding a lone
+// resolved-void parameter the way C's "(void)" parameter list does, and
+// appending a synthesized []byte parameter when variadic is set (go/types
+// requires a variadic signature's last parameter to have slice type).
+func (p *parser) lowerParams(params []*ParamExpr, variadic bool) ([]*types.Var, error) {
+	var args []*types.Var
+	for _, param := range params {
+		t, err := p.lower(param.Type, FlagIsParam)
+		if err != nil {
+			return nil, err
+		}
+		if ctypes.NotVoid(t) {
+			args = append(args, types.NewParam(token.NoPos, p.pkg, param.Name, t))
+		}
+	}
+	if variadic {
+		args = append(args, types.NewParam(token.NoPos, p.pkg, "", types.NewSlice(types.Universe.Lookup("byte").Type())))
+	}
 	return args, nil
 }
 
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+// newTestScope builds a scope with the handful of C builtin type names that
+// ParseType resolves through scope lookup rather than through the
+// unsigned/short/long/signed flags - in the real c2go pipeline these come
+// pre-registered by the converter driving ParseType.
+func newTestScope(pkg *types.Package) *types.Scope {
+	scope := pkg.Scope()
+	scope.Insert(types.NewTypeName(token.NoPos, pkg, "void", types.NewStruct(nil, nil)))
+	scope.Insert(types.NewTypeName(token.NoPos, pkg, "char", types.Typ[types.Int8]))
+	return scope
+}
+
+func parseTestType(t *testing.T, qualType string, flags int) types.Type {
+	t.Helper()
+	pkg := types.NewPackage("", "")
+	typ, _, err := ParseType(token.NewFileSet(), pkg, newTestScope(pkg), qualType, flags)
+	if err != nil {
+		t.Fatalf("ParseType(%q) error: %v", qualType, err)
+	}
+	return typ
+}
+
+// parseTestSignature parses a "ret (*)(params)" qualType and returns its
+// *types.Signature. The "(*)" declarator is pure C syntax marking a
+// function-pointer type; ParseType lowers it straight to a *types.Signature
+// rather than wrapping it in a *types.Pointer.
+func parseTestSignature(t *testing.T, qualType string) *types.Signature {
+	t.Helper()
+	typ := parseTestType(t, qualType, 0)
+	sig, ok := typ.(*types.Signature)
+	if !ok {
+		t.Fatalf("ParseType(%q) = %T, want *types.Signature", qualType, typ)
+	}
+	return sig
+}
+
+func TestParseType_ParamNames(t *testing.T) {
+	cases := []struct {
+		name      string
+		qualType  string
+		wantNames []string
+	}{
+		{"explicit base type", "int (*)(unsigned char z)", []string{"z"}},
+		{"modifier then explicit int", "int (*)(short int w)", []string{"w"}},
+		{"implicit int from a single modifier", "int (*)(unsigned v)", []string{"v"}},
+		{"implicit int from unsigned long", "int (*)(unsigned long x)", []string{"x"}},
+		{"implicit int from long long", "int (*)(long long y)", []string{"y"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sig := parseTestSignature(t, c.qualType)
+			if sig.Params().Len() != len(c.wantNames) {
+				t.Fatalf("Params().Len() = %d, want %d", sig.Params().Len(), len(c.wantNames))
+			}
+			for i, want := range c.wantNames {
+				if got := sig.Params().At(i).Name(); got != want {
+					t.Errorf("param %d name = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseType_ArrayParams(t *testing.T) {
+	cases := []struct {
+		name       string
+		qualType   string
+		wantParams int
+	}{
+		{"array then scalar", "int (*)(char [16], int)", 2},
+		{"scalar then array", "int (*)(int, char [16])", 2},
+		{"sole multi-dimension array param", "int (*)(char [3][4])", 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sig := parseTestSignature(t, c.qualType)
+			if sig.Params().Len() != c.wantParams {
+				t.Fatalf("Params().Len() = %d, want %d", sig.Params().Len(), c.wantParams)
+			}
+		})
+	}
+}
+
+func TestParseType_Variadic(t *testing.T) {
+	cases := []struct {
+		name        string
+		qualType    string
+		wantParams  int
+		wantLastLit string // Underlying().String() of the synthesized last param, if variadic
+	}{
+		{
+			name:        "printf-style prototype",
+			qualType:    "int (*)(const char *, ...)",
+			wantParams:  2,
+			wantLastLit: "[]byte",
+		},
+		{
+			name:       "non-variadic function pointer",
+			qualType:   "int (*)(void *, int, char **, char **)",
+			wantParams: 4,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sig := parseTestSignature(t, c.qualType)
+			wantVariadic := c.wantLastLit != ""
+			if sig.Variadic() != wantVariadic {
+				t.Errorf("Variadic() = %v, want %v", sig.Variadic(), wantVariadic)
+			}
+			if sig.Params().Len() != c.wantParams {
+				t.Errorf("Params().Len() = %d, want %d", sig.Params().Len(), c.wantParams)
+			}
+			if wantVariadic {
+				last := sig.Params().At(sig.Params().Len() - 1)
+				if got := last.Type().Underlying().String(); got != c.wantLastLit {
+					t.Errorf("last param type = %s, want %s", got, c.wantLastLit)
+				}
+			}
+		})
+	}
+}
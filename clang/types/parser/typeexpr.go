@@ -0,0 +1,130 @@
+package parser
+
+import "go/token"
+
+// -----------------------------------------------------------------------------
+
+// TypeExpr is a node in the parse tree ParseTypeExpr builds for a C qualType,
+// before it is lowered into a go/types.Type. It mirrors the shape of the
+// qualType grammar (identifier, pointer, array, function, qualifier) so that
+// callers who need more than the resolved type - parameter names, qualifiers,
+// or a re-rendering of the original C type for diagnostics - don't lose that
+// information at lowering time.
+type TypeExpr interface {
+	Pos() token.Pos
+	End() token.Pos
+}
+
+// IdentType is a base type name, e.g. "int" or "char" (Flags carries the
+// accumulated unsigned/short/long/signed/_Complex modifiers; a bare
+// "unsigned" is Name "int" with the unsigned flag), or a struct/union tag
+// or typedef name looked up in scope.
+type IdentType struct {
+	Name    string
+	Flags   int
+	NamePos token.Pos
+}
+
+func (x *IdentType) Pos() token.Pos { return x.NamePos }
+func (x *IdentType) End() token.Pos { return x.NamePos + token.Pos(len(x.Name)) }
+
+// PointerType is `Elem *`.
+type PointerType struct {
+	Elem TypeExpr
+	Star token.Pos
+}
+
+func (x *PointerType) Pos() token.Pos { return x.Elem.Pos() }
+func (x *PointerType) End() token.Pos { return x.Star + 1 }
+
+// ArrayType is `Elem [Len]`. Len is -1 for an unspecified-length array
+// (`Elem []`).
+type ArrayType struct {
+	Elem   TypeExpr
+	Len    int64
+	Rbrack token.Pos
+}
+
+func (x *ArrayType) Pos() token.Pos { return x.Elem.Pos() }
+func (x *ArrayType) End() token.Pos { return x.Rbrack + 1 }
+
+// ParamExpr is a single function parameter. Name is "" when the qualType
+// doesn't carry one, which is the common case for Clang's qualType strings.
+type ParamExpr struct {
+	Name string
+	Type TypeExpr
+}
+
+// FuncType is a function or function-pointer signature.
+type FuncType struct {
+	Params   []*ParamExpr
+	Result   TypeExpr
+	Variadic bool
+	Rparen   token.Pos
+}
+
+func (x *FuncType) Pos() token.Pos {
+	if x.Result != nil {
+		return x.Result.Pos()
+	}
+	return x.Rparen
+}
+func (x *FuncType) End() token.Pos { return x.Rparen + 1 }
+
+// QualType wraps Inner with one or more of const/volatile/restrict. Unlike
+// IdentType.Flags (which only ever qualifies a base type), a QualType can
+// wrap any TypeExpr - e.g. `char *restrict` qualifies the pointer, while
+// `const char *` qualifies the pointee.
+type QualType struct {
+	Inner                     TypeExpr
+	Const, Volatile, Restrict bool
+}
+
+func (x *QualType) Pos() token.Pos { return x.Inner.Pos() }
+func (x *QualType) End() token.Pos { return x.Inner.End() }
+
+// BadType is a recovery placeholder for a subtree ParseTypeExpr couldn't
+// parse; From/To delimit the skipped source range. It is only ever produced
+// in FlagRecover mode.
+type BadType struct {
+	Literal  string
+	From, To token.Pos
+}
+
+func (x *BadType) Pos() token.Pos { return x.From }
+func (x *BadType) End() token.Pos { return x.To }
+
+// -----------------------------------------------------------------------------
+
+// wrapQual folds const/volatile/restrict onto x, merging into an existing
+// QualType rather than nesting one inside another.
+func wrapQual(x TypeExpr, isConst, isVolatile, isRestrict bool) TypeExpr {
+	if q, ok := x.(*QualType); ok {
+		q.Const = q.Const || isConst
+		q.Volatile = q.Volatile || isVolatile
+		q.Restrict = q.Restrict || isRestrict
+		return q
+	}
+	return &QualType{Inner: x, Const: isConst, Volatile: isVolatile, Restrict: isRestrict}
+}
+
+// isConstExpr reports whether x (or anything it directly wraps, following
+// pointer, array and function-result links but not into function
+// parameters) is const-qualified. This matches ParseType's historical
+// isConst result, which reflects any "const" seen while scanning the
+// top-level qualType.
+func isConstExpr(x TypeExpr) bool {
+	switch n := x.(type) {
+	case *QualType:
+		return n.Const || isConstExpr(n.Inner)
+	case *PointerType:
+		return isConstExpr(n.Elem)
+	case *ArrayType:
+		return isConstExpr(n.Elem)
+	case *FuncType:
+		return isConstExpr(n.Result)
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------